@@ -0,0 +1,175 @@
+// # Copyright (c) 2024 xtaci
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package dppk
+
+import (
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"math/big"
+)
+
+// PEMPrivateKeyType and PEMPublicKeyType are the PEM block types produced by
+// EncodeToPEM and accepted by DecodePEM for MarshalPKCS8PrivateKey and
+// MarshalPKIXPublicKey DER, respectively.
+const (
+	PEMPrivateKeyType = "DPPK PRIVATE KEY"
+	PEMPublicKeyType  = "DPPK PUBLIC KEY"
+)
+
+var (
+	errPEMNoBlock   = errors.New("dppk: no PEM block found")
+	errPEMWrongType = errors.New("dppk: PEM block has the wrong type")
+	errASN1Order    = errors.New("dppk: order field does not match the length of vectorU/vectorV")
+)
+
+// dppkPrivateKeyASN1 is the DER structure produced by MarshalPKCS8PrivateKey:
+//
+//	DPPKPrivateKey ::= SEQUENCE {
+//	    version INTEGER,
+//	    prime   INTEGER,
+//	    order   INTEGER,
+//	    s0      INTEGER,
+//	    a0      INTEGER,
+//	    a1      INTEGER,
+//	    b0      INTEGER,
+//	    b1      INTEGER,
+//	    vectorU SEQUENCE OF INTEGER,
+//	    vectorV SEQUENCE OF INTEGER
+//	}
+type dppkPrivateKeyASN1 struct {
+	Version int
+	Prime   *big.Int
+	Order   int
+	S0      *big.Int
+	A0      *big.Int
+	A1      *big.Int
+	B0      *big.Int
+	B1      *big.Int
+	VectorU []*big.Int
+	VectorV []*big.Int
+}
+
+// dppkPublicKeyASN1 is the DER structure produced by MarshalPKIXPublicKey:
+//
+//	DPPKPublicKey ::= SEQUENCE {
+//	    version INTEGER,
+//	    prime   INTEGER,
+//	    order   INTEGER,
+//	    vectorU SEQUENCE OF INTEGER,
+//	    vectorV SEQUENCE OF INTEGER
+//	}
+type dppkPublicKeyASN1 struct {
+	Version int
+	Prime   *big.Int
+	Order   int
+	VectorU []*big.Int
+	VectorV []*big.Int
+}
+
+// MarshalPKCS8PrivateKey encodes priv as ASN.1 DER, in the style of
+// x509.MarshalPKCS8PrivateKey.
+func MarshalPKCS8PrivateKey(priv *PrivateKey) ([]byte, error) {
+	return asn1.Marshal(dppkPrivateKeyASN1{
+		Version: 0,
+		Prime:   priv.Prime,
+		Order:   priv.PublicKey.Order(),
+		S0:      priv.S0,
+		A0:      priv.A0,
+		A1:      priv.A1,
+		B0:      priv.B0,
+		B1:      priv.B1,
+		VectorU: priv.PublicKey.VectorU,
+		VectorV: priv.PublicKey.VectorV,
+	})
+}
+
+// ParsePKCS8PrivateKey decodes an ASN.1 DER private key produced by
+// MarshalPKCS8PrivateKey.
+func ParsePKCS8PrivateKey(der []byte) (*PrivateKey, error) {
+	var asn1Key dppkPrivateKeyASN1
+	if _, err := asn1.Unmarshal(der, &asn1Key); err != nil {
+		return nil, err
+	}
+
+	if asn1Key.Order != len(asn1Key.VectorU)-1 || len(asn1Key.VectorU) != len(asn1Key.VectorV) {
+		return nil, errASN1Order
+	}
+
+	priv := &PrivateKey{
+		S0: asn1Key.S0,
+		A0: asn1Key.A0,
+		A1: asn1Key.A1,
+		B0: asn1Key.B0,
+		B1: asn1Key.B1,
+	}
+	priv.PublicKey = PublicKey{
+		Prime:   asn1Key.Prime,
+		VectorU: asn1Key.VectorU,
+		VectorV: asn1Key.VectorV,
+	}
+	return priv, nil
+}
+
+// MarshalPKIXPublicKey encodes pub as ASN.1 DER, in the style of
+// x509.MarshalPKIXPublicKey.
+func MarshalPKIXPublicKey(pub *PublicKey) ([]byte, error) {
+	return asn1.Marshal(dppkPublicKeyASN1{
+		Version: 0,
+		Prime:   pub.Prime,
+		Order:   pub.Order(),
+		VectorU: pub.VectorU,
+		VectorV: pub.VectorV,
+	})
+}
+
+// ParsePKIXPublicKey decodes an ASN.1 DER public key produced by
+// MarshalPKIXPublicKey.
+func ParsePKIXPublicKey(der []byte) (*PublicKey, error) {
+	var asn1Key dppkPublicKeyASN1
+	if _, err := asn1.Unmarshal(der, &asn1Key); err != nil {
+		return nil, err
+	}
+
+	if asn1Key.Order != len(asn1Key.VectorU)-1 || len(asn1Key.VectorU) != len(asn1Key.VectorV) {
+		return nil, errASN1Order
+	}
+
+	return &PublicKey{
+		Prime:   asn1Key.Prime,
+		VectorU: asn1Key.VectorU,
+		VectorV: asn1Key.VectorV,
+	}, nil
+}
+
+// EncodeToPEM wraps a MarshalPKCS8PrivateKey/MarshalPKIXPublicKey DER
+// encoding in a PEM block of the given type.
+func EncodeToPEM(blockType string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
+
+// DecodePEM extracts the DER bytes from the first PEM block in data,
+// verifying it has the expected type.
+func DecodePEM(blockType string, data []byte) ([]byte, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errPEMNoBlock
+	}
+	if block.Type != blockType {
+		return nil, errPEMWrongType
+	}
+	return block.Bytes, nil
+}
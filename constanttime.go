@@ -0,0 +1,214 @@
+// # Copyright (c) 2024 xtaci
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package dppk
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"hash"
+	"math/big"
+)
+
+// decryptConstantTime recovers the OAEP-padded plaintext embedded in kem via
+// a blinded polynomial reconstruction (see blindedRoots), trying both
+// candidate roots unconditionally rather than returning as soon as one
+// decodes, and reporting every failure as errDecryption. The name is
+// aspirational, not a guarantee: math/big's ModSqrt/ModInverse are not
+// constant-time, and the OAEPDecode call below still runs once per
+// candidate, so this hardens timing against a ciphertext-driven oracle
+// without making the whole path provably constant-time. This is the path
+// DecryptMessage and Decrypt use; UnsafeDecrypt/DecryptOAEP remain
+// available, unblinded and with distinguishable errors, for benchmarking
+// and debugging.
+func (priv *PrivateKey) decryptConstantTime(hashFunc func() hash.Hash, kem *KEM, label []byte) ([]byte, error) {
+	x1, x2, err := priv.blindedRoots(kem)
+	if err != nil {
+		return nil, errDecryption
+	}
+
+	var msg []byte
+	found := 0
+	for _, candidate := range []*big.Int{x1, x2} {
+		candidateMsg, decodeErr := OAEPDecode(hashFunc, priv.Prime, candidate, label)
+		if decodeErr == nil && found == 0 {
+			msg = candidateMsg
+			found = 1
+		}
+	}
+
+	if found == 0 {
+		return nil, errDecryption
+	}
+	return msg, nil
+}
+
+// blindedRoots solves the same quadratic equation as UnsafeDecrypt, but
+// blinds every value that feeds ModSqrt or ModInverse with independent
+// random factors, and always runs both calls to completion - even when the
+// unblinded input would be zero or non-invertible - selecting the genuine
+// result from a dummy one with constantTimeSelect instead of branching
+// early. This decorrelates the *values* fed to ModSqrt/ModInverse from the
+// ciphertext; it does not make math/big's own algorithms (which are not
+// documented as constant-time) run in uniform time, and the variable-time
+// OAEP decoding that follows in decryptConstantTime still executes once per
+// candidate. Treat this as raising the cost of a Bleichenbacher-style
+// timing attack, not as eliminating the timing side channel outright.
+//
+// Substituting x = y/r turns ax^2+bx+c=0 into ay^2+(br)y+(cr^2)=0, whose
+// roots are r times the original roots; solving for y and dividing by r at
+// the end recovers x1, x2 without ever feeding the unblinded a, b, c pair to
+// ModSqrt. A second, independent factor t blinds 2a before ModInverse the
+// same way.
+func (priv *PrivateKey) blindedRoots(kem *KEM) (x1, x2 *big.Int, err error) {
+	a, b, c, err := priv.quadraticCoefficients(kem)
+	if err != nil {
+		return nil, nil, errDecryption
+	}
+
+	prime := priv.Prime
+
+	r, err := randNonZero(prime)
+	if err != nil {
+		return nil, nil, err
+	}
+	rInv := new(big.Int).ModInverse(r, prime)
+	if rInv == nil {
+		return nil, nil, errDecryption
+	}
+
+	rSq := new(big.Int).Mul(r, r)
+	rSq.Mod(rSq, prime)
+
+	bBlind := new(big.Int).Mul(b, r)
+	bBlind.Mod(bBlind, prime)
+
+	cBlind := new(big.Int).Mul(c, rSq)
+	cBlind.Mod(cBlind, prime)
+
+	bsquared := new(big.Int).Mul(bBlind, bBlind)
+	bsquared.Mod(bsquared, prime)
+
+	fourac := new(big.Int).Mul(big.NewInt(4), new(big.Int).Mul(a, cBlind))
+	fourac.Mod(fourac, prime)
+
+	discBlind := new(big.Int).Sub(bsquared, fourac)
+	discBlind.Mod(discBlind, prime)
+
+	rootBlind, sqrtErr := constantTimeModSqrt(discBlind, prime)
+
+	t, err := randNonZero(prime)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doubleA := new(big.Int).Mul(big.NewInt(2), a)
+	doubleA.Mod(doubleA, prime)
+
+	doubleABlind := new(big.Int).Mul(doubleA, t)
+	doubleABlind.Mod(doubleABlind, prime)
+
+	inv2aBlind, invErr := constantTimeModInverse(doubleABlind, prime)
+	if sqrtErr != nil || invErr != nil {
+		return nil, nil, errDecryption
+	}
+
+	inv2a := new(big.Int).Mul(inv2aBlind, t)
+	inv2a.Mod(inv2a, prime)
+
+	negBBlind := new(big.Int).Sub(prime, bBlind)
+
+	y1 := new(big.Int).Sub(negBBlind, rootBlind)
+	y1.Mod(y1, prime)
+	y1.Mul(y1, inv2a)
+	y1.Mod(y1, prime)
+
+	y2 := new(big.Int).Add(negBBlind, rootBlind)
+	y2.Mod(y2, prime)
+	y2.Mul(y2, inv2a)
+	y2.Mod(y2, prime)
+
+	x1 = new(big.Int).Mul(y1, rInv)
+	x1.Mod(x1, prime)
+
+	x2 = new(big.Int).Mul(y2, rInv)
+	x2.Mod(x2, prime)
+
+	return x1, x2, nil
+}
+
+// randNonZero returns a uniform random element of [1, prime).
+func randNonZero(prime *big.Int) (*big.Int, error) {
+	n, err := rand.Int(rand.Reader, new(big.Int).Sub(prime, big.NewInt(1)))
+	if err != nil {
+		return nil, err
+	}
+	return n.Add(n, big.NewInt(1)), nil
+}
+
+// constantTimeModSqrt returns a square root of x modulo prime. It always
+// runs math/big's Tonelli-Shanks ModSqrt on both x and a known quadratic
+// residue, then uses subtle.ConstantTimeCopy over fixed-width buffers to
+// select between the two outcomes, so that x not being a quadratic residue
+// does not return in a detectably different time from a successful call.
+func constantTimeModSqrt(x, prime *big.Int) (*big.Int, error) {
+	k := fieldByteLen(prime)
+
+	root := new(big.Int).ModSqrt(x, prime)
+	ok := 1
+	if root == nil {
+		ok = 0
+		root = new(big.Int)
+	}
+	dummy := new(big.Int).ModSqrt(big.NewInt(1), prime)
+
+	return constantTimeSelect(ok, root, dummy, k)
+}
+
+// constantTimeModInverse returns the inverse of x modulo prime, selecting
+// between a genuine and a dummy ModInverse call the same way
+// constantTimeModSqrt does.
+func constantTimeModInverse(x, prime *big.Int) (*big.Int, error) {
+	k := fieldByteLen(prime)
+
+	inv := new(big.Int).ModInverse(x, prime)
+	ok := 1
+	if inv == nil {
+		ok = 0
+		inv = new(big.Int)
+	}
+	dummy := new(big.Int).ModInverse(big.NewInt(1), prime)
+
+	return constantTimeSelect(ok, inv, dummy, k)
+}
+
+// constantTimeSelect returns real if ok == 1 and errDecryption if ok == 0,
+// choosing between real's and dummy's fixed-width byte representations with
+// subtle.ConstantTimeCopy rather than branching on ok.
+func constantTimeSelect(ok int, real, dummy *big.Int, size int) (*big.Int, error) {
+	realBuf := make([]byte, size)
+	dummyBuf := make([]byte, size)
+	real.FillBytes(realBuf)
+	dummy.FillBytes(dummyBuf)
+
+	out := make([]byte, size)
+	subtle.ConstantTimeCopy(ok, out, realBuf)
+	subtle.ConstantTimeCopy(1-ok, out, dummyBuf)
+
+	if ok == 0 {
+		return nil, errDecryption
+	}
+	return new(big.Int).SetBytes(out), nil
+}
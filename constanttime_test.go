@@ -0,0 +1,101 @@
+// # Copyright (c) 2024 xtaci
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package dppk
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlindedRootsMatchesUnsafeDecrypt(t *testing.T) {
+	alice, err := GenerateKey(10)
+	assert.Nil(t, err)
+
+	secret := []byte("hello quantum")
+	kem, err := Encrypt(&alice.PublicKey, secret)
+	assert.Nil(t, err)
+
+	wantX1, wantX2, err := alice.UnsafeDecrypt(kem)
+	assert.Nil(t, err)
+
+	gotX1, gotX2, err := alice.blindedRoots(kem)
+	assert.Nil(t, err)
+
+	// blindedRoots solves the same quadratic as UnsafeDecrypt but via a
+	// fresh random substitution each call, so the pair it returns can come
+	// back in either order; only the unordered set of roots is guaranteed
+	// to match.
+	want := map[string]bool{wantX1.String(): true, wantX2.String(): true}
+	assert.True(t, want[gotX1.String()])
+	assert.True(t, want[gotX2.String()])
+	assert.NotEqual(t, gotX1.String(), gotX2.String())
+}
+
+func TestDecryptMessageConstantTimePath(t *testing.T) {
+	alice, err := GenerateKey(10)
+	assert.Nil(t, err)
+
+	secret := []byte("hello quantum")
+	kem, err := Encrypt(&alice.PublicKey, secret)
+	assert.Nil(t, err)
+
+	message, err := alice.DecryptMessage(kem)
+	assert.Nil(t, err)
+	assert.Equal(t, secret, message)
+}
+
+func TestDecryptMessageTamperedCiphertextCollapsesError(t *testing.T) {
+	alice, err := GenerateKey(10)
+	assert.Nil(t, err)
+
+	secret := []byte("hello quantum")
+	kem, err := Encrypt(&alice.PublicKey, secret)
+	assert.Nil(t, err)
+
+	tamperedPs := new(big.Int).Xor(kem.Ps, big.NewInt(1))
+	tampered := &KEM{Ps: tamperedPs, Qs: kem.Qs}
+
+	_, err = alice.DecryptMessage(tampered)
+	assert.Equal(t, errDecryption, err)
+}
+
+func TestConstantTimeModSqrtNonResidue(t *testing.T) {
+	alice, err := GenerateKey(10)
+	assert.Nil(t, err)
+
+	nonResidue := new(big.Int)
+	for {
+		candidate, cerr := randNonZero(alice.Prime)
+		assert.Nil(t, cerr)
+		if new(big.Int).ModSqrt(candidate, alice.Prime) == nil {
+			nonResidue = candidate
+			break
+		}
+	}
+
+	_, err = constantTimeModSqrt(nonResidue, alice.Prime)
+	assert.Equal(t, errDecryption, err)
+}
+
+func TestConstantTimeModInverseZero(t *testing.T) {
+	alice, err := GenerateKey(10)
+	assert.Nil(t, err)
+
+	_, err = constantTimeModInverse(big.NewInt(0), alice.Prime)
+	assert.Equal(t, errDecryption, err)
+}
@@ -0,0 +1,100 @@
+// # Copyright (c) 2024 xtaci
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package dppk
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"io"
+	"math/big"
+)
+
+var errCiphertextSize = errors.New("dppk: ciphertext has the wrong length for this key's field size")
+
+// Opts selects the decryption mode for PrivateKey.Decrypt, mirroring
+// rsa.OAEPOptions. The zero value decodes msg with OAEP and SHA-256, the
+// same default Encrypt/DecryptMessage use.
+type Opts struct {
+	// Raw selects raw field-element decryption: the secret is recovered
+	// and returned as its big-endian bytes, with no OAEP unpadding. Hash
+	// and Label are ignored when Raw is true.
+	Raw bool
+
+	// Hash is the hash function used for OAEP decoding; it defaults to
+	// SHA-256 when nil.
+	Hash func() hash.Hash
+
+	// Label is the OAEP label, which must match the label used at
+	// encryption.
+	Label []byte
+}
+
+// Decrypt implements crypto.Decrypter. rand is ignored, as DPPK decryption
+// is deterministic given the private key and ciphertext. msg must be a KEM
+// ciphertext encoded by EncryptOAEP/Encrypt's wire format (marshalKEM:
+// Ps || Qs); opts, if non-nil, must be a *Opts selecting raw or OAEP
+// decoding. Both modes recover the roots via the blinded decryptConstantTime/
+// recoverVerifiedSecret path rather than UnsafeDecrypt, so the default,
+// standard-library-shaped entry point never runs the unblinded root recovery
+// on caller-supplied ciphertext.
+func (priv *PrivateKey) Decrypt(_ io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	ct, err := parseKEMBytes(priv.Prime, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	dppkOpts, _ := opts.(*Opts)
+	if dppkOpts != nil && dppkOpts.Raw {
+		return priv.decryptRawSecret(ct)
+	}
+
+	hashFunc := sha256.New
+	var label []byte
+	if dppkOpts != nil {
+		if dppkOpts.Hash != nil {
+			hashFunc = dppkOpts.Hash
+		}
+		label = dppkOpts.Label
+	}
+
+	return priv.decryptConstantTime(hashFunc, ct, label)
+}
+
+// decryptRawSecret recovers the secret embedded in ct and returns it as
+// unpadded big-endian bytes. Because the two quadratic roots are not a
+// simple +/-x pair, there is no public test that tells them apart; lacking
+// OAEP padding to check, this reuses the same re-encrypt-and-compare
+// verification Decapsulate uses to pick the right candidate.
+func (priv *PrivateKey) decryptRawSecret(ct *KEM) ([]byte, error) {
+	ok, secret := priv.recoverVerifiedSecret(ct)
+	if !ok {
+		return nil, errDecryption
+	}
+
+	return secret.Bytes(), nil
+}
+
+// parseKEMBytes decodes a wire-format KEM ciphertext produced for prime,
+// verifying its length matches exactly.
+func parseKEMBytes(prime *big.Int, msg []byte) (*KEM, error) {
+	k := fieldByteLen(prime)
+	if len(msg) != 2*k {
+		return nil, errCiphertextSize
+	}
+	return unmarshalKEM(msg), nil
+}
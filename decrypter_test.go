@@ -0,0 +1,66 @@
+// # Copyright (c) 2024 xtaci
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package dppk
+
+import (
+	"crypto"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Compile-time check that *PrivateKey satisfies crypto.Decrypter.
+var _ crypto.Decrypter = (*PrivateKey)(nil)
+
+func TestPrivateKeyDecrypterOAEP(t *testing.T) {
+	alice, err := GenerateKey(10)
+	assert.Nil(t, err)
+
+	secret := []byte("hello quantum")
+	kem, err := Encrypt(&alice.PublicKey, secret)
+	assert.Nil(t, err)
+
+	msg := marshalKEM(alice.Prime, kem)
+
+	var decrypter crypto.Decrypter = alice
+	message, err := decrypter.Decrypt(nil, msg, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, secret, message)
+}
+
+func TestPrivateKeyDecrypterRaw(t *testing.T) {
+	alice, err := GenerateKey(10)
+	assert.Nil(t, err)
+
+	secret := big.NewInt(424242)
+	kem, err := encryptSecret(&alice.PublicKey, new(big.Int).Set(secret), alice.Prime)
+	assert.Nil(t, err)
+
+	msg := marshalKEM(alice.Prime, kem)
+
+	raw, err := alice.Decrypt(nil, msg, &Opts{Raw: true})
+	assert.Nil(t, err)
+	assert.Equal(t, secret, new(big.Int).SetBytes(raw))
+}
+
+func TestPrivateKeyDecrypterWrongLength(t *testing.T) {
+	alice, err := GenerateKey(10)
+	assert.Nil(t, err)
+
+	_, err = alice.Decrypt(nil, []byte{0x01, 0x02, 0x03}, nil)
+	assert.Equal(t, errCiphertextSize, err)
+}
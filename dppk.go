@@ -16,8 +16,12 @@
 package dppk
 
 import (
+	"crypto"
 	"crypto/rand"
+	"crypto/sha256"
 	"errors"
+	"hash"
+	"io"
 	"math/big"
 )
 
@@ -31,15 +35,12 @@ const (
 	ERR_MSG_VU_PUBLICKEY  = "VU in public key is not equal"
 )
 
-const secretMarker = "\x5f\x37\x59\xdf"
-
 // defaultPrime is the prime number used in cryptographic operations.
 var defaultPrime *big.Int
 var (
-	errInvalidPrime        = errors.New("Invalid Prime")
-	errNoQuadraticResidue  = errors.New("ciphertext is not a quadratic residue")
-	errSingularQuadratic   = errors.New("no modular inverse for quadratic coefficient")
-	errInvalidSecretFormat = errors.New("invalid secret encoding")
+	errInvalidPrime       = errors.New("Invalid Prime")
+	errNoQuadraticResidue = errors.New("ciphertext is not a quadratic residue")
+	errSingularQuadratic  = errors.New("no modular inverse for quadratic coefficient")
 )
 
 func init() {
@@ -108,6 +109,12 @@ func (pub *PublicKey) Order() int {
 	return len(pub.VectorU) - 1
 }
 
+// Size returns the number of bytes needed to hold any element of GF(prime),
+// the size of a raw Ps or Qs ciphertext component.
+func (pub *PublicKey) Size() int {
+	return fieldByteLen(pub.Prime)
+}
+
 // GenerateKey generates a new DPPK private key with the given order and prime number
 // the prime number is a string formatted in base 10
 func GenerateKeyWithPrime(order int, strPrime string) (*PrivateKey, error) {
@@ -215,26 +222,37 @@ RETRY:
 	priv.Prime = prime
 	priv.PublicKey.VectorU = vecU[1 : order+2]
 	priv.PublicKey.VectorV = vecV[1 : order+2]
+
 	return priv, nil
 }
 
-// encrypt encrypts a message with the given public key and the prime specified in public key
-
-func encodeSecret(msg []byte) []byte {
-	encoded := make([]byte, len(msg)+len(secretMarker))
-	copy(encoded, secretMarker)
-	copy(encoded[len(secretMarker):], msg)
-	return encoded
+// Encrypt OAEP-encodes msg with SHA-256 and encrypts it with the given
+// public key. It is equivalent to EncryptOAEP(pub, sha256.New, rand.Reader,
+// msg, nil).
+func Encrypt(pub *PublicKey, msg []byte) (kem *KEM, err error) {
+	return EncryptOAEP(pub, sha256.New, rand.Reader, msg, nil)
 }
 
-func Encrypt(pub *PublicKey, msg []byte) (kem *KEM, err error) {
-	return encrypt(pub, msg, pub.Prime)
+// EncryptOAEP OAEP-encodes msg (see OAEPEncode) and encrypts the resulting
+// field element with the given public key, in the style of
+// rsa.EncryptOAEP. label is bound into the padding via lHash and must match
+// the label passed to DecryptOAEP.
+func EncryptOAEP(pub *PublicKey, hashFunc func() hash.Hash, random io.Reader, msg, label []byte) (kem *KEM, err error) {
+	if pub.Prime == nil {
+		return nil, errors.New(ERR_MSG_VU_PUBLICKEY)
+	}
+
+	secret, err := OAEPEncode(hashFunc, random, pub.Prime, msg, label)
+	if err != nil {
+		return nil, err
+	}
+
+	return encryptSecret(pub, secret, pub.Prime)
 }
 
-// encrypt encrypts a message with the given public key.
-func encrypt(pub *PublicKey, msg []byte, prime *big.Int) (kem *KEM, err error) {
-	// Convert the message to a big integer
-	secret := new(big.Int).SetBytes(encodeSecret(msg))
+// encryptSecret encrypts a field element already encoded as a DPPK secret
+// with the given public key.
+func encryptSecret(pub *PublicKey, secret *big.Int, prime *big.Int) (kem *KEM, err error) {
 	if secret.Cmp(prime) >= 0 {
 		return nil, errors.New(ERR_MSG_DATA_EXCEEDED)
 	}
@@ -288,16 +306,41 @@ func encrypt(pub *PublicKey, msg []byte, prime *big.Int) (kem *KEM, err error) {
 	return &KEM{Ps: Ps, Qs: Qs}, nil
 }
 
-// Decrypt decrypts the encrypted values Ps and Qs using the private key.
-func (priv *PrivateKey) Decrypt(kem *KEM) (x1, x2 *big.Int, err error) {
+// quadraticCoefficients reduces kem to the coefficients a, b, c of the
+// quadratic equation ax^2 + bx + c = 0 satisfied by the secret.
+//
+// Explanation:
+// As:
+//
+//	Ps := Bn * (x^2 + a1x + a0) mod p
+//	Qs := Bn * (x^2 + b1x + b0) mod p
+//
+// multiply the reverse of Bn on the both side of the equation, we have:
+//
+//	Ps*revBn(s):= (x^2 + a1x + a0) mod p
+//	Qs*revBn(s):= (x^2 + b1x + b0) mod p
+//
+// to align the left and right side of the equation, we have:
+//
+//	Ps* Qs * revBn(s):= (x^2 + a1x + a0) * Qs mod p
+//	Ps* Qs * revBn(s):= (x^2 + b1x + b0) * Ps mod p
+//
+// and evidently:
+//
+//	(x^2 + a1x + a0) * Qs  == (x^2 + b1x + b0) * Ps modp
+//
+// Solve this equation to get x
+// the following procedure will be formalized to :
+// ax^2 + bx + c = 0
+func (priv *PrivateKey) quadraticCoefficients(kem *KEM) (a, b, c *big.Int, err error) {
 	if kem == nil {
-		return nil, nil, errors.New(ERR_MSG_NULL_ENCRYPT)
+		return nil, nil, nil, errors.New(ERR_MSG_NULL_ENCRYPT)
 	}
 
 	Ps := kem.Ps
 	Qs := kem.Qs
 	if Ps == nil || Qs == nil {
-		return nil, nil, errors.New(ERR_MSG_NULL_ENCRYPT)
+		return nil, nil, nil, errors.New(ERR_MSG_NULL_ENCRYPT)
 	}
 
 	prime := priv.Prime
@@ -318,32 +361,12 @@ func (priv *PrivateKey) Decrypt(kem *KEM) (x1, x2 *big.Int, err error) {
 	polyQ.Add(polyQ, s0b0)
 	polyQ.Mod(polyQ, prime)
 
-	// Explanation:
-	// As:
-	//      Ps := Bn * (x^2 + a1x + a0) mod p
-	//      Qs := Bn * (x^2 + b1x + b0) mod p
-	//
-	// multiply the reverse of Bn on the both side of the equation, we have:
-	//      Ps*revBn(s):= (x^2 + a1x + a0) mod p
-	//      Qs*revBn(s):= (x^2 + b1x + b0) mod p
-	//
-	// to align the left and right side of the equation, we have:
-	//      Ps* Qs * revBn(s):= (x^2 + a1x + a0) * Qs mod p
-	//      Ps* Qs * revBn(s):= (x^2 + b1x + b0) * Ps mod p
-	//
-	// and evidently:
-	//      (x^2 + a1x + a0) * Qs  == (x^2 + b1x + b0) * Ps modp
-	//
-	// Solve this equation to get x
-	// the following procedure will be formalized to :
-	// ax^2 + bx + c = 0
-
-	a := new(big.Int)
+	a = new(big.Int)
 	revPs := new(big.Int).Sub(prime, polyP)
 	a.Add(polyQ, revPs)
 	a.Mod(a, priv.Prime)
 
-	b := new(big.Int)
+	b = new(big.Int)
 	a1Qs := new(big.Int).Mul(polyQ, priv.A1)
 	b1Ps := new(big.Int).Mul(polyP, priv.B1)
 	b1Ps.Mod(b1Ps, priv.Prime)
@@ -351,7 +374,7 @@ func (priv *PrivateKey) Decrypt(kem *KEM) (x1, x2 *big.Int, err error) {
 	b.Add(a1Qs, revb1Ps)
 	b.Mod(b, priv.Prime)
 
-	c := new(big.Int)
+	c = new(big.Int)
 	a0Qs := new(big.Int).Mul(polyQ, priv.A0)
 	b0Ps := new(big.Int).Mul(polyP, priv.B0)
 	b0Ps.Mod(b0Ps, priv.Prime)
@@ -359,6 +382,35 @@ func (priv *PrivateKey) Decrypt(kem *KEM) (x1, x2 *big.Int, err error) {
 	c.Add(a0Qs, revb0Ps)
 	c.Mod(c, priv.Prime)
 
+	return a, b, c, nil
+}
+
+// UnsafeDecrypt decrypts the encrypted values Ps and Qs using the private
+// key, returning both candidate roots of the underlying quadratic equation.
+// It runs ModSqrt and ModInverse directly on ciphertext-derived values and
+// returns errNoQuadraticResidue/errSingularQuadratic verbatim, so its timing
+// and error identity can leak information about a maliciously crafted
+// ciphertext; it exists for benchmarking and debugging the unblinded
+// arithmetic. Application code should use DecryptMessage, DecryptOAEP, or
+// the crypto.Decrypter Decrypt method instead.
+//
+// There is no single-root variant of this function: x1 and x2 are the two
+// roots of a general quadratic, not a +/-x pair, and by Vieta's formulas
+// x1*x2 = c/a varies with the ciphertext rather than being a fixed scheme
+// invariant. A Jacobi-symbol (or other residue-class) test over x1*x2 is
+// therefore ciphertext-dependent and cannot pick out the genuine root in
+// general; the earlier attempt at this was reverted. Disambiguation instead
+// happens one layer up, via OAEP-decode success (DecryptOAEP,
+// decryptConstantTime) or Fujisaki-Okamoto re-encryption verification
+// (recoverVerifiedSecret).
+func (priv *PrivateKey) UnsafeDecrypt(kem *KEM) (x1, x2 *big.Int, err error) {
+	a, b, c, err := priv.quadraticCoefficients(kem)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prime := priv.Prime
+
 	// Solve the quadratic equation derived from Ps and Qs
 	// Compute the discriminant of the quadratic equation
 	bsquared := new(big.Int).Mul(b, b)
@@ -410,43 +462,40 @@ func (priv *PrivateKey) Decrypt(kem *KEM) (x1, x2 *big.Int, err error) {
 	return x1, x2, nil
 }
 
-// DecryptMessage returns the plaintext message embedded in the ciphertext.
-// It tries both candidate roots and returns the first one that matches the
-// expected secret encoding marker.
+// DecryptMessage recovers the OAEP-padded plaintext embedded in the
+// ciphertext using SHA-256, via the blinded, timing-hardened decryption path
+// (see decryptConstantTime). It is equivalent to
+// priv.decryptConstantTime(sha256.New, kem, nil).
 func (priv *PrivateKey) DecryptMessage(kem *KEM) ([]byte, error) {
-	x1, x2, err := priv.Decrypt(kem)
-	if err != nil {
-		return nil, err
-	}
-
-	if msg, err := RecoverMessage(x1); err == nil {
-		return msg, nil
-	}
-
-	if msg, err := RecoverMessage(x2); err == nil {
-		return msg, nil
-	}
-
-	return nil, errInvalidSecretFormat
+	return priv.decryptConstantTime(sha256.New, kem, nil)
 }
 
-// RecoverMessage converts a decrypted root into the original plaintext.
-func RecoverMessage(candidate *big.Int) ([]byte, error) {
-	if candidate == nil {
-		return nil, errInvalidSecretFormat
+// DecryptOAEP recovers the plaintext embedded in the ciphertext using the
+// unblinded, variable-time UnsafeDecrypt path. The two roots UnsafeDecrypt
+// returns are roots of a general quadratic, not a +/-x pair, so there is no
+// public test that picks out the one encryption actually used; this tries
+// OAEP-decoding (see OAEPDecode) each of x1 and x2 in turn and returns the
+// first one that decodes successfully. Most callers should prefer
+// DecryptMessage, which runs the same logic over the blinded, timing-hardened
+// decryptConstantTime path.
+func (priv *PrivateKey) DecryptOAEP(hashFunc func() hash.Hash, kem *KEM, label []byte) ([]byte, error) {
+	x1, x2, err := priv.UnsafeDecrypt(kem)
+	if err != nil {
+		return nil, err
 	}
 
-	raw := candidate.Bytes()
-	if len(raw) < len(secretMarker) || string(raw[:len(secretMarker)]) != secretMarker {
-		return nil, errInvalidSecretFormat
+	for _, candidate := range []*big.Int{x1, x2} {
+		if msg, err := OAEPDecode(hashFunc, priv.Prime, candidate, label); err == nil {
+			return msg, nil
+		}
 	}
 
-	msg := make([]byte, len(raw)-len(secretMarker))
-	copy(msg, raw[len(secretMarker):])
-	return msg, nil
+	return nil, errDecryption
 }
 
-// Public returns the public key of the private key.
-func (priv *PrivateKey) Public() *PublicKey {
+// Public returns the public key corresponding to priv, implementing
+// crypto.Decrypter. Callers that need the concrete type can access
+// priv.PublicKey directly instead of type-asserting the result.
+func (priv *PrivateKey) Public() crypto.PublicKey {
 	return &priv.PublicKey
 }
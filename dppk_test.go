@@ -16,7 +16,8 @@
 package dppk
 
 import (
-	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -35,21 +36,18 @@ func TestDPPK(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, secret, message)
 
-	x1, x2, err := alice.Decrypt(kem)
+	x1, x2, err := alice.UnsafeDecrypt(kem)
 	assert.Nil(t, err)
-	t.Log("x1:", string(x1.Bytes()))
-	t.Log("x2:", string(x2.Bytes()))
+	t.Log("x1:", x1.Text(16))
+	t.Log("x2:", x2.Text(16))
 
-	assert.Equal(t, alice.Public().Order(), 10)
-
-	decoded1, err1 := RecoverMessage(x1)
-	decoded2, err2 := RecoverMessage(x2)
-	equal := (err1 == nil && bytes.Equal(secret, decoded1)) || (err2 == nil && bytes.Equal(secret, decoded2))
-	assert.True(t, equal)
+	assert.Equal(t, alice.PublicKey.Order(), 10)
 }
 
 func TestDPPKSmallPrime(t *testing.T) {
-	prime := "977"
+	// Large enough to hold OAEP overhead (2*SHA-256 size + 2 bytes) plus a
+	// one-byte message, but still much smaller than DefaultPrime.
+	prime := "0xdf4c9cdef2e9fd9b652708aab301e8c7ce6d15c02d85991d00020f8c74b6898a06a6f86f77b10e7b64aeed3e7f537ab3604916c88bf0d23445e440de59f3c5e89bd21fd973e702cc8e9f0bef9f361977"
 	alice, err := GenerateKeyWithPrime(10, prime)
 	assert.Nil(t, err)
 
@@ -61,16 +59,22 @@ func TestDPPKSmallPrime(t *testing.T) {
 	message, err := alice.DecryptMessage(kem)
 	assert.Nil(t, err)
 	assert.Equal(t, secret, message)
+}
+
+func TestDPPKOAEPLabelMismatch(t *testing.T) {
+	alice, err := GenerateKey(10)
+	assert.Nil(t, err)
 
-	x1, x2, err := alice.Decrypt(kem)
+	secret := []byte("hello quantum")
+	kem, err := EncryptOAEP(&alice.PublicKey, sha256.New, rand.Reader, secret, []byte("label-a"))
 	assert.Nil(t, err)
-	t.Log("x1:", string(x1.Bytes()))
-	t.Log("x2:", string(x2.Bytes()))
 
-	decoded1, err1 := RecoverMessage(x1)
-	decoded2, err2 := RecoverMessage(x2)
-	equal := (err1 == nil && bytes.Equal(secret, decoded1)) || (err2 == nil && bytes.Equal(secret, decoded2))
-	assert.True(t, equal)
+	_, err = alice.DecryptOAEP(sha256.New, kem, []byte("label-b"))
+	assert.Equal(t, errDecryption, err)
+
+	message, err := alice.DecryptOAEP(sha256.New, kem, []byte("label-a"))
+	assert.Nil(t, err)
+	assert.Equal(t, secret, message)
 }
 
 func TestDPPKLeadingZeros(t *testing.T) {
@@ -86,6 +90,36 @@ func TestDPPKLeadingZeros(t *testing.T) {
 	assert.Equal(t, secret, message)
 }
 
+// TestDPPKRootSelection encrypts enough distinct messages to exercise both
+// orderings of UnsafeDecrypt's two roots (the real secret coming back as x1
+// some of the time and as x2 the rest), checking that DecryptMessage still
+// recovers the right one by OAEP-decode success in every case.
+func TestDPPKRootSelection(t *testing.T) {
+	alice, err := GenerateKey(10)
+	assert.Nil(t, err)
+
+	var sawX1, sawX2 bool
+	for i := 0; i < 50 && !(sawX1 && sawX2); i++ {
+		secret := []byte{byte(i), 0x42, 0x10}
+		kem, err := Encrypt(&alice.PublicKey, secret)
+		assert.Nil(t, err)
+
+		message, err := alice.DecryptMessage(kem)
+		assert.Nil(t, err)
+		assert.Equal(t, secret, message)
+
+		x1, _, err := alice.UnsafeDecrypt(kem)
+		assert.Nil(t, err)
+		if _, decodeErr := OAEPDecode(sha256.New, alice.Prime, x1, nil); decodeErr == nil {
+			sawX1 = true
+		} else {
+			sawX2 = true
+		}
+	}
+	assert.True(t, sawX1, "expected the real secret to come back as x1 at least once")
+	assert.True(t, sawX2, "expected the real secret to come back as x2 at least once")
+}
+
 func BenchmarkDPPKEncrypt(b *testing.B) {
 	dppk, _ := GenerateKey(5)
 	secret := []byte("hello quantum")
@@ -99,6 +133,6 @@ func BenchmarkDPPKDecrypt(b *testing.B) {
 	secret := []byte("hello quantum")
 	kem, _ := Encrypt(&dppk.PublicKey, secret)
 	for i := 0; i < b.N; i++ {
-		_, _, _ = dppk.Decrypt(kem)
+		_, _, _ = dppk.UnsafeDecrypt(kem)
 	}
 }
@@ -0,0 +1,221 @@
+// # Copyright (c) 2024 xtaci
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package hybrid
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/binary"
+	"io"
+	"math/big"
+
+	"github.com/xtaci/dppk"
+)
+
+// MarshalPublicKey serializes a hybrid public key as a length-prefixed DPPK
+// public key followed by the 32-byte X25519 public key:
+//
+//	uint32(len(dppkBytes)) || dppkBytes || x25519Bytes
+func MarshalPublicKey(pub *PublicKey) ([]byte, error) {
+	dppkBytes := marshalDPPKPublicKey(pub.DPPK)
+	x25519Bytes := pub.X25519.Bytes()
+
+	out := make([]byte, 0, 4+len(dppkBytes)+len(x25519Bytes))
+	out = appendUint32Prefixed(out, dppkBytes)
+	out = append(out, x25519Bytes...)
+	return out, nil
+}
+
+// UnmarshalPublicKey reverses MarshalPublicKey.
+func UnmarshalPublicKey(data []byte) (*PublicKey, error) {
+	dppkBytes, rest, err := readUint32Prefixed(data)
+	if err != nil {
+		return nil, err
+	}
+
+	dppkPub, err := unmarshalDPPKPublicKey(dppkBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	x25519Pub, err := ecdh.X25519().NewPublicKey(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PublicKey{DPPK: dppkPub, X25519: x25519Pub}, nil
+}
+
+// MarshalCiphertext serializes a hybrid ciphertext as a length-prefixed
+// DPPK KEM ciphertext followed by the 32-byte ephemeral X25519 public key:
+//
+//	uint32(len(dppkCtBytes)) || dppkCtBytes || x25519EphemeralBytes
+func MarshalCiphertext(ct *Ciphertext) ([]byte, error) {
+	dppkBytes := marshalDPPKKEM(ct.DPPK)
+	ephBytes := ct.X25519Ephemeral.Bytes()
+
+	out := make([]byte, 0, 4+len(dppkBytes)+len(ephBytes))
+	out = appendUint32Prefixed(out, dppkBytes)
+	out = append(out, ephBytes...)
+	return out, nil
+}
+
+// UnmarshalCiphertext reverses MarshalCiphertext.
+func UnmarshalCiphertext(data []byte) (*Ciphertext, error) {
+	dppkBytes, rest, err := readUint32Prefixed(data)
+	if err != nil {
+		return nil, err
+	}
+
+	dppkCt, err := unmarshalDPPKKEM(dppkBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	eph, err := ecdh.X25519().NewPublicKey(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Ciphertext{DPPK: dppkCt, X25519Ephemeral: eph}, nil
+}
+
+// marshalDPPKPublicKey encodes a DPPK public key as its prime, the number of
+// elements in VectorU (written once and read back for both vectors), then
+// the elements of U and of V, each integer length-prefixed.
+func marshalDPPKPublicKey(pub *dppk.PublicKey) []byte {
+	var buf bytes.Buffer
+	writeBigInt(&buf, pub.Prime)
+	writeUint32(&buf, uint32(len(pub.VectorU)))
+	for _, u := range pub.VectorU {
+		writeBigInt(&buf, u)
+	}
+	for _, v := range pub.VectorV {
+		writeBigInt(&buf, v)
+	}
+	return buf.Bytes()
+}
+
+// unmarshalDPPKPublicKey reverses marshalDPPKPublicKey.
+func unmarshalDPPKPublicKey(data []byte) (*dppk.PublicKey, error) {
+	r := bytes.NewReader(data)
+
+	prime, err := readBigInt(r)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
+	vecU := make([]*big.Int, n)
+	for i := range vecU {
+		if vecU[i], err = readBigInt(r); err != nil {
+			return nil, err
+		}
+	}
+
+	vecV := make([]*big.Int, n)
+	for i := range vecV {
+		if vecV[i], err = readBigInt(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return &dppk.PublicKey{Prime: prime, VectorU: vecU, VectorV: vecV}, nil
+}
+
+// marshalDPPKKEM encodes a DPPK KEM ciphertext as Ps and Qs.
+func marshalDPPKKEM(ct *dppk.KEM) []byte {
+	var buf bytes.Buffer
+	writeBigInt(&buf, ct.Ps)
+	writeBigInt(&buf, ct.Qs)
+	return buf.Bytes()
+}
+
+// unmarshalDPPKKEM reverses marshalDPPKKEM.
+func unmarshalDPPKKEM(data []byte) (*dppk.KEM, error) {
+	r := bytes.NewReader(data)
+
+	ps, err := readBigInt(r)
+	if err != nil {
+		return nil, err
+	}
+
+	qs, err := readBigInt(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dppk.KEM{Ps: ps, Qs: qs}, nil
+}
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	buf.Write(b[:])
+}
+
+func readUint32(r *bytes.Reader) (uint32, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b[:]), nil
+}
+
+func writeBigInt(buf *bytes.Buffer, x *big.Int) {
+	b := x.Bytes()
+	writeUint32(buf, uint32(len(b)))
+	buf.Write(b)
+}
+
+func readBigInt(r *bytes.Reader) (*big.Int, error) {
+	n, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// appendUint32Prefixed appends a big-endian uint32 length prefix followed
+// by b to out.
+func appendUint32Prefixed(out, b []byte) []byte {
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(b)))
+	out = append(out, lenBuf[:]...)
+	return append(out, b...)
+}
+
+// readUint32Prefixed splits data into the length-prefixed block and the
+// remaining bytes after it.
+func readUint32Prefixed(data []byte) (block, rest []byte, err error) {
+	if len(data) < 4 {
+		return nil, nil, errShortBuffer
+	}
+	n := binary.BigEndian.Uint32(data[:4])
+	data = data[4:]
+	if uint64(len(data)) < uint64(n) {
+		return nil, nil, errShortBuffer
+	}
+	return data[:n], data[n:], nil
+}
@@ -0,0 +1,154 @@
+// # Copyright (c) 2024 xtaci
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package hybrid combines a DPPK KEM with a classical X25519 key exchange,
+// following the pattern used for SIKE+X25519 in TLS. The combined shared
+// secret binds both halves into a single transcript, so a session remains
+// as strong as X25519 even if DPPK's polynomial hardness assumption is
+// ever broken, and as strong as DPPK if X25519 ever falls to a quantum
+// attack.
+package hybrid
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/xtaci/dppk"
+)
+
+// hybridInfo is the HKDF info string binding the derived key to this combiner.
+const hybridInfo = "dppk-x25519-hybrid-v1"
+
+var errShortBuffer = errors.New("hybrid: truncated buffer")
+
+// PublicKey is a hybrid public key combining a DPPK public key with an
+// X25519 public key.
+type PublicKey struct {
+	DPPK   *dppk.PublicKey
+	X25519 *ecdh.PublicKey
+}
+
+// PrivateKey is a hybrid private key combining a DPPK private key with an
+// X25519 private key.
+type PrivateKey struct {
+	DPPK   *dppk.PrivateKey
+	X25519 *ecdh.PrivateKey
+	PublicKey
+}
+
+// Ciphertext is the combined encapsulation output: the DPPK KEM ciphertext
+// alongside the ephemeral X25519 public key.
+type Ciphertext struct {
+	DPPK            *dppk.KEM
+	X25519Ephemeral *ecdh.PublicKey
+}
+
+// GenerateHybridKey generates a new hybrid private key, using a DPPK
+// private key of the given polynomial order and the default prime.
+func GenerateHybridKey(order int) (*PrivateKey, error) {
+	dppkPriv, err := dppk.GenerateKey(order)
+	if err != nil {
+		return nil, err
+	}
+
+	x25519Priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	priv := &PrivateKey{DPPK: dppkPriv, X25519: x25519Priv}
+	priv.PublicKey.DPPK = &dppkPriv.PublicKey
+	priv.PublicKey.X25519 = x25519Priv.PublicKey()
+	return priv, nil
+}
+
+// Encapsulate performs a combined DPPK + X25519 key exchange against pub,
+// returning the combined ciphertext and the derived 32-byte shared secret.
+func Encapsulate(pub *PublicKey) (ct *Ciphertext, sharedSecret [32]byte, err error) {
+	dppkCt, dppkSecret, err := dppk.Encapsulate(pub.DPPK)
+	if err != nil {
+		return nil, sharedSecret, err
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, sharedSecret, err
+	}
+
+	x25519Secret, err := ephemeral.ECDH(pub.X25519)
+	if err != nil {
+		return nil, sharedSecret, err
+	}
+
+	ct = &Ciphertext{DPPK: dppkCt, X25519Ephemeral: ephemeral.PublicKey()}
+
+	transcript, err := hybridTranscript(pub, ct)
+	if err != nil {
+		return nil, sharedSecret, err
+	}
+
+	ikm := append(append([]byte{}, dppkSecret[:]...), x25519Secret...)
+	okm := hkdfSHA256(ikm, transcript, []byte(hybridInfo), 32)
+	copy(sharedSecret[:], okm)
+
+	return ct, sharedSecret, nil
+}
+
+// Decapsulate reverses Encapsulate, re-deriving the same shared secret from
+// ct using priv.
+func (priv *PrivateKey) Decapsulate(ct *Ciphertext) (sharedSecret [32]byte, err error) {
+	dppkSecret, err := priv.DPPK.Decapsulate(ct.DPPK)
+	if err != nil {
+		return sharedSecret, err
+	}
+
+	x25519Secret, err := priv.X25519.ECDH(ct.X25519Ephemeral)
+	if err != nil {
+		return sharedSecret, err
+	}
+
+	transcript, err := hybridTranscript(&priv.PublicKey, ct)
+	if err != nil {
+		return sharedSecret, err
+	}
+
+	ikm := append(append([]byte{}, dppkSecret[:]...), x25519Secret...)
+	okm := hkdfSHA256(ikm, transcript, []byte(hybridInfo), 32)
+	copy(sharedSecret[:], okm)
+
+	return sharedSecret, nil
+}
+
+// hybridTranscript binds the public key and ciphertext together so that
+// neither the DPPK half nor the X25519 half of the exchange can be
+// substituted independently.
+func hybridTranscript(pub *PublicKey, ct *Ciphertext) ([]byte, error) {
+	pubBytes, err := MarshalPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	ctBytes, err := MarshalCiphertext(ct)
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	h.Write(pubBytes)
+	h.Write(ctBytes)
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,81 @@
+// # Copyright (c) 2024 xtaci
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package hybrid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHybridRoundTrip(t *testing.T) {
+	alice, err := GenerateHybridKey(10)
+	assert.Nil(t, err)
+
+	ct, sharedSecret, err := Encapsulate(&alice.PublicKey)
+	assert.Nil(t, err)
+
+	recovered, err := alice.Decapsulate(ct)
+	assert.Nil(t, err)
+	assert.Equal(t, sharedSecret, recovered)
+}
+
+func TestHybridPublicKeyMarshalRoundTrip(t *testing.T) {
+	alice, err := GenerateHybridKey(10)
+	assert.Nil(t, err)
+
+	data, err := MarshalPublicKey(&alice.PublicKey)
+	assert.Nil(t, err)
+
+	pub, err := UnmarshalPublicKey(data)
+	assert.Nil(t, err)
+	assert.True(t, pub.DPPK.Equal(alice.PublicKey.DPPK))
+	assert.Equal(t, alice.PublicKey.X25519.Bytes(), pub.X25519.Bytes())
+}
+
+func TestHybridCiphertextMarshalCrossCompatibility(t *testing.T) {
+	alice, err := GenerateHybridKey(10)
+	assert.Nil(t, err)
+
+	ct, sharedSecret, err := Encapsulate(&alice.PublicKey)
+	assert.Nil(t, err)
+
+	data, err := MarshalCiphertext(ct)
+	assert.Nil(t, err)
+
+	decoded, err := UnmarshalCiphertext(data)
+	assert.Nil(t, err)
+
+	recovered, err := alice.Decapsulate(decoded)
+	assert.Nil(t, err)
+	assert.Equal(t, sharedSecret, recovered)
+}
+
+func TestHybridDecapsulateWrongKey(t *testing.T) {
+	alice, err := GenerateHybridKey(10)
+	assert.Nil(t, err)
+
+	bob, err := GenerateHybridKey(10)
+	assert.Nil(t, err)
+
+	ct, sharedSecret, err := Encapsulate(&alice.PublicKey)
+	assert.Nil(t, err)
+
+	recovered, err := bob.Decapsulate(ct)
+	if err == nil {
+		assert.NotEqual(t, sharedSecret, recovered)
+	}
+}
@@ -0,0 +1,270 @@
+// # Copyright (c) 2024 xtaci
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package dppk
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// kemInfo is the HKDF info string binding the derived key to this KEM.
+const kemInfo = "dppk-kem-v1"
+
+var errSealedTooShort = errors.New("dppk: sealed message is too short")
+
+// Encapsulate samples a uniform secret field element, encrypts it for pub
+// with the current polynomial construction, and derives a 32-byte shared
+// secret from it via HKDF-SHA256. The returned ct is the only thing that
+// needs to cross the wire; sharedSecret never does.
+func Encapsulate(pub *PublicKey) (ct *KEM, sharedSecret [32]byte, err error) {
+	if pub.Prime == nil {
+		return nil, sharedSecret, errors.New(ERR_MSG_VU_PUBLICKEY)
+	}
+
+	s, err := rand.Int(rand.Reader, pub.Prime)
+	if err != nil {
+		return nil, sharedSecret, err
+	}
+
+	ct, err = encryptSecret(pub, new(big.Int).Set(s), pub.Prime)
+	if err != nil {
+		return nil, sharedSecret, err
+	}
+
+	return ct, deriveSharedSecret(pub.Prime, s), nil
+}
+
+// Decapsulate re-derives the shared secret that ct encapsulates. Following
+// the Fujisaki-Okamoto transform, it recovers the candidate secret,
+// re-encrypts it, and compares the result against ct; on any mismatch it
+// returns an implicit-reject key derived from priv's own secret material
+// instead of an error, so that decapsulation failure is not distinguishable
+// from success. Root recovery goes through the blinded, timing-hardened path
+// (see blindedRoots) rather than UnsafeDecrypt, so that a malicious ct
+// cannot use ModSqrt/ModInverse timing as a Bleichenbacher-style oracle
+// against this, the primary CCA-secure entry point.
+func (priv *PrivateKey) Decapsulate(ct *KEM) (sharedSecret [32]byte, err error) {
+	if priv.Prime == nil {
+		return sharedSecret, errors.New(ERR_MSG_VU_PUBLICKEY)
+	}
+	if ct == nil || ct.Ps == nil || ct.Qs == nil {
+		return sharedSecret, errors.New(ERR_MSG_NULL_ENCRYPT)
+	}
+
+	valid, s := priv.recoverVerifiedSecret(ct)
+	if !valid {
+		return priv.implicitRejectKey(ct), nil
+	}
+
+	return deriveSharedSecret(priv.Prime, s), nil
+}
+
+// recoverVerifiedSecret tries each candidate root, recovered via the
+// blinded, timing-hardened blindedRoots, and returns the first one whose
+// re-encryption matches ct exactly.
+func (priv *PrivateKey) recoverVerifiedSecret(ct *KEM) (bool, *big.Int) {
+	x1, x2, err := priv.blindedRoots(ct)
+	if err != nil {
+		return false, nil
+	}
+
+	for _, candidate := range []*big.Int{x1, x2} {
+		reencap, err := encryptSecret(&priv.PublicKey, new(big.Int).Set(candidate), priv.Prime)
+		if err == nil && kemEqual(reencap, ct, priv.Prime) {
+			return true, candidate
+		}
+	}
+
+	return false, nil
+}
+
+// implicitRejectKey derives a pseudo-random 32-byte key from priv's secret
+// polynomial coefficients and ct, so that a failed decapsulation returns a
+// key indistinguishable from a real one instead of an error.
+func (priv *PrivateKey) implicitRejectKey(ct *KEM) [32]byte {
+	k := fieldByteLen(priv.Prime)
+
+	mac := hmac.New(sha256.New, priv.rejectionKeyMaterial())
+	mac.Write(leftPad(ct.Ps.Bytes(), k))
+	mac.Write(leftPad(ct.Qs.Bytes(), k))
+
+	var out [32]byte
+	copy(out[:], mac.Sum(nil))
+	return out
+}
+
+// rejectionKeyMaterial returns the private coefficients used as the HMAC key
+// for implicitRejectKey. It is secret key material known only to priv's
+// holder, never transmitted, and unrelated to any shared secret.
+func (priv *PrivateKey) rejectionKeyMaterial() []byte {
+	var buf []byte
+	buf = append(buf, priv.S0.Bytes()...)
+	buf = append(buf, priv.A0.Bytes()...)
+	buf = append(buf, priv.A1.Bytes()...)
+	buf = append(buf, priv.B0.Bytes()...)
+	buf = append(buf, priv.B1.Bytes()...)
+	return buf
+}
+
+// kemEqual reports whether a and b encode the same ciphertext, comparing
+// the field elements in constant time.
+func kemEqual(a, b *KEM, prime *big.Int) bool {
+	k := fieldByteLen(prime)
+	psEqual := subtle.ConstantTimeCompare(leftPad(a.Ps.Bytes(), k), leftPad(b.Ps.Bytes(), k))
+	qsEqual := subtle.ConstantTimeCompare(leftPad(a.Qs.Bytes(), k), leftPad(b.Qs.Bytes(), k))
+	return psEqual == 1 && qsEqual == 1
+}
+
+// deriveSharedSecret derives the 32-byte KEM key from a secret field element
+// via HKDF-SHA256(secret=I2OSP(s), salt=nil, info="dppk-kem-v1").
+func deriveSharedSecret(prime *big.Int, s *big.Int) [32]byte {
+	ikm := leftPad(s.Bytes(), fieldByteLen(prime))
+	okm := hkdfSHA256(ikm, nil, []byte(kemInfo), 32)
+
+	var out [32]byte
+	copy(out[:], okm)
+	return out
+}
+
+// fieldByteLen returns the number of bytes needed to hold any element of
+// Z_prime without truncation.
+func fieldByteLen(prime *big.Int) int {
+	return (prime.BitLen() + 7) / 8
+}
+
+// hkdfSHA256 implements RFC 5869 HKDF-Extract/Expand with SHA-256.
+func hkdfSHA256(ikm, salt, info []byte, length int) []byte {
+	if len(salt) == 0 {
+		salt = make([]byte, sha256.Size)
+	}
+
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(ikm)
+	prk := extract.Sum(nil)
+
+	okm := make([]byte, 0, length)
+	var t []byte
+	for counter := byte(1); len(okm) < length; counter++ {
+		expand := hmac.New(sha256.New, prk)
+		expand.Write(t)
+		expand.Write(info)
+		expand.Write([]byte{counter})
+		t = expand.Sum(nil)
+		okm = append(okm, t...)
+	}
+
+	return okm[:length]
+}
+
+// Seal encapsulates a fresh KEM key for pub, then encrypts plaintext under
+// that key with AES-256-GCM, authenticating aad. The result packs
+// KEM || nonce || ciphertext||tag into a single blob so callers can encrypt
+// arbitrary-length payloads instead of being limited to one field element.
+func Seal(pub *PublicKey, plaintext, aad []byte) ([]byte, error) {
+	ct, sharedSecret, err := Encapsulate(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(sharedSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	ctBytes := marshalKEM(pub.Prime, ct)
+	sealed := gcm.Seal(nil, nonce, plaintext, aad)
+
+	blob := make([]byte, 0, len(ctBytes)+len(nonce)+len(sealed))
+	blob = append(blob, ctBytes...)
+	blob = append(blob, nonce...)
+	blob = append(blob, sealed...)
+	return blob, nil
+}
+
+// Open reverses Seal: it parses the KEM ciphertext prefix out of sealed,
+// decapsulates the shared key, and opens the AES-256-GCM payload,
+// authenticating aad.
+func (priv *PrivateKey) Open(sealed, aad []byte) ([]byte, error) {
+	if priv.Prime == nil {
+		return nil, errors.New(ERR_MSG_VU_PUBLICKEY)
+	}
+
+	k := fieldByteLen(priv.Prime)
+	ctLen := 2 * k
+	if len(sealed) < ctLen {
+		return nil, errSealedTooShort
+	}
+
+	ct := unmarshalKEM(sealed[:ctLen])
+
+	sharedSecret, err := priv.Decapsulate(ct)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(sharedSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := sealed[ctLen:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, errSealedTooShort
+	}
+	nonce, ciphertext := rest[:gcm.NonceSize()], rest[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+// newGCM builds an AES-256-GCM AEAD from a 32-byte KEM key.
+func newGCM(key [32]byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// marshalKEM encodes ct as two fixed-width, big-endian field elements:
+// Ps || Qs.
+func marshalKEM(prime *big.Int, ct *KEM) []byte {
+	k := fieldByteLen(prime)
+	buf := make([]byte, 2*k)
+	copy(buf[0:k], leftPad(ct.Ps.Bytes(), k))
+	copy(buf[k:2*k], leftPad(ct.Qs.Bytes(), k))
+	return buf
+}
+
+// unmarshalKEM reverses marshalKEM.
+func unmarshalKEM(buf []byte) *KEM {
+	k := len(buf) / 2
+	return &KEM{
+		Ps: new(big.Int).SetBytes(buf[0:k]),
+		Qs: new(big.Int).SetBytes(buf[k : 2*k]),
+	}
+}
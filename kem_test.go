@@ -0,0 +1,86 @@
+// # Copyright (c) 2024 xtaci
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package dppk
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKEMRoundTrip(t *testing.T) {
+	alice, err := GenerateKey(10)
+	assert.Nil(t, err)
+
+	ct, sharedSecret, err := Encapsulate(&alice.PublicKey)
+	assert.Nil(t, err)
+
+	recovered, err := alice.Decapsulate(ct)
+	assert.Nil(t, err)
+	assert.Equal(t, sharedSecret, recovered)
+}
+
+func TestKEMDecapsulateImplicitReject(t *testing.T) {
+	alice, err := GenerateKey(10)
+	assert.Nil(t, err)
+
+	ct, sharedSecret, err := Encapsulate(&alice.PublicKey)
+	assert.Nil(t, err)
+
+	// Corrupt the ciphertext; decapsulation must not error, and must return
+	// a key that does not match the one derived from the honest encapsulation.
+	tamperedPs := new(big.Int).Xor(ct.Ps, big.NewInt(1))
+	tampered := &KEM{Ps: tamperedPs, Qs: ct.Qs}
+
+	rejected, err := alice.Decapsulate(tampered)
+	assert.Nil(t, err)
+	assert.NotEqual(t, sharedSecret, rejected)
+}
+
+func TestKEMSealOpen(t *testing.T) {
+	alice, err := GenerateKey(10)
+	assert.Nil(t, err)
+
+	plaintext := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog, repeated to exceed GF(p) "), 4)
+	aad := []byte("header")
+
+	sealed, err := Seal(&alice.PublicKey, plaintext, aad)
+	assert.Nil(t, err)
+
+	opened, err := alice.Open(sealed, aad)
+	assert.Nil(t, err)
+	assert.Equal(t, plaintext, opened)
+
+	_, err = alice.Open(sealed, []byte("wrong-aad"))
+	assert.NotNil(t, err)
+}
+
+func TestKEMSealOpenTamperedCiphertext(t *testing.T) {
+	alice, err := GenerateKey(10)
+	assert.Nil(t, err)
+
+	plaintext := []byte("hello quantum")
+	sealed, err := Seal(&alice.PublicKey, plaintext, nil)
+	assert.Nil(t, err)
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 0xff
+
+	_, err = alice.Open(tampered, nil)
+	assert.NotNil(t, err)
+}
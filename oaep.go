@@ -0,0 +1,214 @@
+// # Copyright (c) 2024 xtaci
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package dppk
+
+import (
+	"crypto/subtle"
+	"errors"
+	"hash"
+	"io"
+	"math/big"
+)
+
+// errDecryption is returned for every OAEP decoding failure - wrong length,
+// a label mismatch, or a missing 0x01 separator are all indistinguishable
+// from one another so that error identity cannot be used as a decryption
+// oracle.
+var errDecryption = errors.New("dppk: decryption error")
+
+// errMessageTooLong is returned by OAEPEncode when msg does not fit in the
+// field alongside the OAEP overhead.
+var errMessageTooLong = errors.New("dppk: message too long for OAEP encoding")
+
+// OAEPEncode pads msg into a field element suitable for DPPK encryption,
+// following the Rabin-OAEP construction:
+//
+//	EM = 0x00 || maskedSeed || maskedDB
+//	DB = lHash || 0x00...0x00 || 0x01 || M
+//
+// where k = (prime.BitLen()-1)/8 is the byte length of EM (fewer bytes than
+// fieldByteLen(prime)), hLen is
+// the size of hashFunc's digest, and lHash = hashFunc(label). The seed is
+// sampled uniformly from random; because the field is not a power of two,
+// OAEPEncode resamples the seed whenever the encoded integer lands >= prime.
+func OAEPEncode(hashFunc func() hash.Hash, random io.Reader, prime *big.Int, msg, label []byte) (*big.Int, error) {
+	hLen := hashFunc().Size()
+	k := (prime.BitLen() - 1) / 8
+
+	mLen := len(msg)
+	if k < 2*hLen+2 || mLen > k-2*hLen-2 {
+		return nil, errMessageTooLong
+	}
+
+	lHash := hashSum(hashFunc, label)
+
+	db := make([]byte, k-hLen-1)
+	copy(db, lHash)
+	db[len(db)-mLen-1] = 0x01
+	copy(db[len(db)-mLen:], msg)
+
+	seed := make([]byte, hLen)
+	em := make([]byte, k)
+	for {
+		if _, err := io.ReadFull(random, seed); err != nil {
+			return nil, err
+		}
+
+		dbMask := make([]byte, len(db))
+		mgf1XOR(dbMask, hashFunc, seed)
+		maskedDB := make([]byte, len(db))
+		for i := range db {
+			maskedDB[i] = db[i] ^ dbMask[i]
+		}
+
+		seedMask := make([]byte, hLen)
+		mgf1XOR(seedMask, hashFunc, maskedDB)
+		maskedSeed := make([]byte, hLen)
+		for i := range seed {
+			maskedSeed[i] = seed[i] ^ seedMask[i]
+		}
+
+		em[0] = 0x00
+		copy(em[1:1+hLen], maskedSeed)
+		copy(em[1+hLen:], maskedDB)
+
+		secret := new(big.Int).SetBytes(em)
+		if secret.Cmp(prime) < 0 {
+			return secret, nil
+		}
+	}
+}
+
+// OAEPDecode reverses OAEPEncode, recovering msg from a decrypted secret
+// field element. Malformed padding, a label mismatch, and a missing 0x01
+// separator all collapse into errDecryption so that no error distinguishes
+// which internal check failed.
+func OAEPDecode(hashFunc func() hash.Hash, prime *big.Int, secret *big.Int, label []byte) ([]byte, error) {
+	hLen := hashFunc().Size()
+	k := (prime.BitLen() - 1) / 8
+	if k < 2*hLen+2 {
+		return nil, errDecryption
+	}
+
+	em := leftPad(secret.Bytes(), k)
+	if len(em) != k {
+		return nil, errDecryption
+	}
+
+	firstByteIsZero := subtle.ConstantTimeByteEq(em[0], 0)
+
+	maskedSeed := em[1 : 1+hLen]
+	maskedDB := em[1+hLen:]
+
+	seedMask := make([]byte, hLen)
+	mgf1XOR(seedMask, hashFunc, maskedDB)
+	seed := make([]byte, hLen)
+	for i := range seed {
+		seed[i] = maskedSeed[i] ^ seedMask[i]
+	}
+
+	dbMask := make([]byte, len(maskedDB))
+	mgf1XOR(dbMask, hashFunc, seed)
+	db := make([]byte, len(maskedDB))
+	for i := range db {
+		db[i] = maskedDB[i] ^ dbMask[i]
+	}
+
+	lHash := hashSum(hashFunc, label)
+	lHashGood := subtle.ConstantTimeCompare(lHash, db[:hLen])
+
+	// Find the 0x01 separator following lHash without branching on its
+	// position, so the timing does not reveal where (or whether) it occurs.
+	rest := db[hLen:]
+	lookingForIndex := 1
+	index := 0
+	invalid := 0
+	for i := 0; i < len(rest); i++ {
+		equals0 := subtle.ConstantTimeByteEq(rest[i], 0)
+		equals1 := subtle.ConstantTimeByteEq(rest[i], 1)
+		index = subtle.ConstantTimeSelect(lookingForIndex&equals1, i, index)
+		lookingForIndex = subtle.ConstantTimeSelect(equals1, 0, lookingForIndex)
+		invalid = subtle.ConstantTimeSelect(lookingForIndex&^equals0, 1, invalid)
+	}
+
+	// Combine every check into a single flag with bitwise ops rather than
+	// short-circuiting ||, so evaluating the final verdict does not itself
+	// add a branch on which check failed.
+	good := firstByteIsZero
+	good &= lHashGood
+	good &= subtle.ConstantTimeEq(int32(invalid), 0)
+	good &= subtle.ConstantTimeEq(int32(lookingForIndex), 0)
+	if good == 0 {
+		return nil, errDecryption
+	}
+
+	msg := make([]byte, len(rest)-index-1)
+	copy(msg, rest[index+1:])
+	return msg, nil
+}
+
+// leftPad returns b left-padded with zeros to size bytes. If b is already
+// longer than size, it is returned unchanged so the caller can detect the
+// oversize condition.
+func leftPad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
+
+// hashSum returns hashFunc(data).
+func hashSum(hashFunc func() hash.Hash, data []byte) []byte {
+	h := hashFunc()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// mgf1XOR XORs the MGF1 mask generated from seed using hashFunc into out, in
+// the style of crypto/rsa's internal mask generation function.
+func mgf1XOR(out []byte, hashFunc func() hash.Hash, seed []byte) {
+	h := hashFunc()
+	var counter [4]byte
+	var done int
+	for done < len(out) {
+		h.Reset()
+		h.Write(seed)
+		h.Write(counter[:])
+		digest := h.Sum(nil)
+
+		for i := 0; i < len(digest) && done < len(out); i++ {
+			out[done] ^= digest[i]
+			done++
+		}
+		incCounter(&counter)
+	}
+}
+
+// incCounter increments a 4-byte, big-endian counter in place.
+func incCounter(c *[4]byte) {
+	if c[3]++; c[3] != 0 {
+		return
+	}
+	if c[2]++; c[2] != 0 {
+		return
+	}
+	if c[1]++; c[1] != 0 {
+		return
+	}
+	c[0]++
+}